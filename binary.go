@@ -0,0 +1,241 @@
+package bitarray
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// binaryVersion is the first byte of every stream produced by WriteTo/MarshalBinary,
+// so a future incompatible format change can be detected instead of misread.
+const binaryVersion = 1
+
+// marshalTag is the second byte of the stream. It mostly mirrors Type, except
+// BitArrayType splits into two tags so the raw and WAH encodings can both be
+// written, with the smaller one chosen at marshal time.
+type marshalTag byte
+
+const (
+	tagIndexes marshalTag = iota
+	tagWAH
+	tagBitArrayRaw
+	tagBitArrayWAH
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler. The result round-trips through
+// UnmarshalBinary losslessly regardless of the BitArray's internal state.
+func (s *BitArray) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing s's contents with
+// the BitArray encoded in data.
+func (s *BitArray) UnmarshalBinary(data []byte) error {
+	_, err := s.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+// WriteTo implements io.WriterTo. It streams version, tag, length and payload
+// straight to w, so a caller persisting many BitArrays can write each one in turn
+// without buffering the whole set in memory.
+func (s *BitArray) WriteTo(w io.Writer) (int64, error) {
+	s.mux.Lock()
+	tag, values := s.marshalValues()
+	s.mux.Unlock()
+
+	var (
+		total int64
+		tmp   [binary.MaxVarintLen64]byte
+	)
+	write := func(p []byte) error {
+		n, err := w.Write(p)
+		total += int64(n)
+		return err
+	}
+
+	if err := write([]byte{binaryVersion, byte(tag)}); err != nil {
+		return total, err
+	}
+	n := binary.PutUvarint(tmp[:], uint64(len(values)))
+	if err := write(tmp[:n]); err != nil {
+		return total, err
+	}
+	for _, v := range values {
+		n := binary.PutUvarint(tmp[:], v)
+		if err := write(tmp[:n]); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// ReadFrom implements io.ReaderFrom, replacing s's contents with the BitArray
+// encoded at the start of r. It reads exactly the bytes that make up the stream, so
+// callers can call ReadFrom repeatedly on a shared io.Reader to decode a sequence of
+// BitArrays.
+func (s *BitArray) ReadFrom(r io.Reader) (int64, error) {
+	br := &byteReader{r: r}
+
+	version, err := br.ReadByte()
+	if err != nil {
+		return br.n, err
+	}
+	if version != binaryVersion {
+		return br.n, fmt.Errorf("bitarray: unsupported binary version %d", version)
+	}
+
+	tagByte, err := br.ReadByte()
+	if err != nil {
+		return br.n, err
+	}
+
+	length, err := binary.ReadUvarint(br)
+	if err != nil {
+		return br.n, err
+	}
+
+	values := make([]uint64, length)
+	for i := range values {
+		v, err := binary.ReadUvarint(br)
+		if err != nil {
+			return br.n, err
+		}
+		values[i] = v
+	}
+
+	if err := s.unmarshalValues(marshalTag(tagByte), values); err != nil {
+		return br.n, err
+	}
+	return br.n, nil
+}
+
+// marshalValues reduces s's current state to a tag and a flat list of values that
+// can each be written as a single varint. Indexes are delta-encoded against the
+// previous index, WAH words are written as-is, and for BitArrayType the raw and WAH
+// encodings are both built so the smaller one can be picked.
+func (s *BitArray) marshalValues() (marshalTag, []uint64) {
+	switch s.state {
+	case IndexesType:
+		keys := s.getOffsets()
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		values := make([]uint64, len(keys))
+		var prev uint32
+		for i, k := range keys {
+			values[i] = uint64(k - prev)
+			prev = k
+		}
+		return tagIndexes, values
+	case WAHType:
+		return tagWAH, widenWords(s.compressed)
+	default: // BitArrayType
+		s.checkBitArray()
+		raw := widenWords(s.uncompressed)
+
+		data := append([]uint32(nil), s.uncompressed...)
+		s.compress(data)
+		wah := widenWords(s.compressed)
+
+		if varintLen(wah) < varintLen(raw) {
+			return tagBitArrayWAH, wah
+		}
+		return tagBitArrayRaw, raw
+	}
+}
+
+// unmarshalValues is the inverse of marshalValues: given the tag and values read
+// from the stream, it rebuilds s's internal state directly, without going through
+// Create, since it is populating an existing receiver rather than a new one.
+func (s *BitArray) unmarshalValues(tag marshalTag, values []uint64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.isDirty = false
+	s.curMax = 0
+	s.offsets = make(map[uint32]bool)
+	s.compressed = nil
+	s.uncompressed = nil
+	s.ranks = nil
+
+	switch tag {
+	case tagIndexes:
+		s.state = IndexesType
+		var cur uint32
+		for _, delta := range values {
+			cur += uint32(delta)
+			s.offsets[cur] = true
+			if cur > s.curMax {
+				s.curMax = cur
+			}
+		}
+	case tagWAH:
+		s.state = WAHType
+		s.compressed = narrowWords(values)
+	case tagBitArrayRaw:
+		s.state = BitArrayType
+		s.uncompressed = narrowWords(values)
+	case tagBitArrayWAH:
+		s.compressed = narrowWords(values)
+		s.uncompress()
+		s.state = BitArrayType
+		s.compressed = s.compressed[:0]
+	default:
+		return fmt.Errorf("bitarray: unknown binary tag %d", tag)
+	}
+	s.publish()
+	return nil
+}
+
+func widenWords(words []uint32) []uint64 {
+	values := make([]uint64, len(words))
+	for i, w := range words {
+		values[i] = uint64(w)
+	}
+	return values
+}
+
+func narrowWords(values []uint64) []uint32 {
+	words := make([]uint32, len(values))
+	for i, v := range values {
+		words[i] = uint32(v)
+	}
+	return words
+}
+
+// varintLen returns the number of bytes binary.PutUvarint would need to encode
+// every value in values, used to compare candidate encodings without building them.
+func varintLen(values []uint64) int {
+	n := 0
+	for _, v := range values {
+		for {
+			n++
+			v >>= 7
+			if v == 0 {
+				break
+			}
+		}
+	}
+	return n
+}
+
+// byteReader adapts an io.Reader to io.ByteReader one byte at a time, so
+// binary.ReadUvarint never reads past the end of the current BitArray's stream
+// the way a buffered reader would.
+type byteReader struct {
+	r io.Reader
+	n int64
+}
+
+func (b *byteReader) ReadByte() (byte, error) {
+	var buf [1]byte
+	if _, err := io.ReadFull(b.r, buf[:]); err != nil {
+		return 0, err
+	}
+	b.n++
+	return buf[0], nil
+}
@@ -0,0 +1,95 @@
+package bitarray
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalBinaryIndexesType(t *testing.T) {
+	b1 := New()
+	for _, i := range []uint32{1, 5, 100, 100000} {
+		b1.Set(i, true)
+	}
+
+	data, err := b1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	b2 := New()
+	if err := b2.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if !reflect.DeepEqual(b1.GetBitIndexes(), b2.GetBitIndexes()) {
+		t.Fatalf("expected indexes %v, got %v", b1.GetBitIndexes(), b2.GetBitIndexes())
+	}
+}
+
+func TestMarshalBinaryBitArrayType(t *testing.T) {
+	b1 := New()
+	for i := uint32(0); i < 200; i += 3 {
+		b1.Set(i, true)
+	}
+	data, err := b1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	b2 := New()
+	if err := b2.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if !reflect.DeepEqual(b1.GetBitIndexes(), b2.GetBitIndexes()) {
+		t.Fatalf("expected indexes %v, got %v", b1.GetBitIndexes(), b2.GetBitIndexes())
+	}
+}
+
+func TestMarshalBinaryWAHType(t *testing.T) {
+	b1 := New()
+	for i := uint32(0); i < 200; i += 3 {
+		b1.Set(i, true)
+	}
+	b1.FreeMemory()
+
+	data, err := b1.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	b2 := New()
+	if err := b2.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if !reflect.DeepEqual(b1.GetBitIndexes(), b2.GetBitIndexes()) {
+		t.Fatalf("expected indexes %v, got %v", b1.GetBitIndexes(), b2.GetBitIndexes())
+	}
+}
+
+func TestReadFromMultipleBitArrays(t *testing.T) {
+	b1, b2 := New(), New()
+	b1.Set(1, true)
+	b2.Set(2, true)
+
+	var buf bytes.Buffer
+	if _, err := b1.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if _, err := b2.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+
+	r1, r2 := New(), New()
+	if _, err := r1.ReadFrom(&buf); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	if _, err := r2.ReadFrom(&buf); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	if !reflect.DeepEqual(r1.GetBitIndexes(), []uint32{1}) {
+		t.Fatalf("expected [1], got %v", r1.GetBitIndexes())
+	}
+	if !reflect.DeepEqual(r2.GetBitIndexes(), []uint32{2}) {
+		t.Fatalf("expected [2], got %v", r2.GetBitIndexes())
+	}
+}
@@ -1,10 +1,19 @@
 /*
 Package bitarray provides an object type which efficiently represents an array of booleans.
+
+BitArray indexes positions with a uint32, which keeps the small-input case cheap but
+caps the usable range at around 4.29 billion bits. BitArray64, generated from this
+file by gen.go, is the same implementation addressed with a uint64 index for callers
+that need more room.
 */
 package bitarray
 
+//go:generate go run gen.go
+
 import (
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 type Type int
@@ -17,13 +26,189 @@ const (
 
 // BitArray is an array data structure that compactly stores bits.
 type BitArray struct {
-	mux          sync.Mutex
+	mux          sync.RWMutex
 	state        Type
 	curMax       uint32
 	isDirty      bool
+	frozen       bool
 	offsets      map[uint32]bool
 	compressed   []uint32
 	uncompressed []uint32
+	// ranks holds, for BitArrayType, the cumulative popcount at every
+	// rankSampleStride-th word. It is built lazily by ensureRanks and treated as
+	// stale whenever isDirty is set, so Set doesn't pay for upkeep it may never need.
+	ranks []uint32
+	// snap holds an immutable copy of the bits last published by Set, Create,
+	// unmarshalValues or FreeMemory, so Get can read it without taking mux at all.
+	// checkBitArray/compress don't republish on their own, since they never change
+	// which bits are set; FreeMemory does, since it switches the representation a
+	// snapshot decodes (chunks vs compressed) even though the bits themselves don't
+	// change.
+	snap atomic.Pointer[bitArrayState]
+}
+
+// snapChunkWords is the granularity at which a BitArrayType snapshot's words are
+// copied on write. publishWord only clones the one chunk a Set touched (plus any
+// chunks a resize newly added) and reuses every other chunk by reference from the
+// previous snapshot, so a Set costs O(snapChunkWords) instead of O(current size).
+const snapChunkWords = 1024
+
+// bitArrayState is an immutable, point-in-time view of a BitArray's bits, safe to
+// read without holding mux. Exactly one of offsets, compressed or chunks is
+// populated, matching typ.
+type bitArrayState struct {
+	typ        Type
+	offsets    map[uint32]bool
+	compressed []uint32
+	// chunks holds BitArrayType's words split into fixed-size, independently
+	// shareable slices; see snapChunkWords.
+	chunks [][]uint32
+}
+
+// chunkWords splits words into snapChunkWords-sized chunks, each an independent
+// copy so it can be shared by reference across snapshots.
+func chunkWords(words []uint32) [][]uint32 {
+	if len(words) == 0 {
+		return nil
+	}
+	chunks := make([][]uint32, 0, (len(words)+snapChunkWords-1)/snapChunkWords)
+	for i := 0; i < len(words); i += snapChunkWords {
+		end := i + snapChunkWords
+		if end > len(words) {
+			end = len(words)
+		}
+		chunk := make([]uint32, end-i)
+		copy(chunk, words[i:end])
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// publish snapshots s's current bits into a fresh bitArrayState and makes it
+// visible to lock-free readers. It rebuilds the snapshot from scratch; Create,
+// New, UnmarshalBinary and FreeMemory use it, and so does every Set that
+// publishAfterSet doesn't route to the cheaper publishWord.
+// Callers must hold mux (for writing) already.
+func (s *BitArray) publish() {
+	st := &bitArrayState{typ: s.state}
+	switch s.state {
+	case IndexesType:
+		offsets := make(map[uint32]bool, len(s.offsets))
+		for k, v := range s.offsets {
+			offsets[k] = v
+		}
+		st.offsets = offsets
+	case WAHType:
+		st.compressed = append([]uint32(nil), s.compressed...)
+	default:
+		st.chunks = chunkWords(s.uncompressed)
+	}
+	s.snap.Store(st)
+}
+
+// publishWord republishes after a Set that only touched the word at index (and
+// possibly grew s.uncompressed), reusing every chunk prev already has unchanged
+// instead of recopying the whole array.
+func (s *BitArray) publishWord(prev *bitArrayState, index uint32) {
+	touchedChunk := int(index>>5) / snapChunkWords
+	nChunks := (len(s.uncompressed) + snapChunkWords - 1) / snapChunkWords
+
+	chunks := make([][]uint32, nChunks)
+	copy(chunks, prev.chunks)
+
+	rebuild := func(i int) []uint32 {
+		start := i * snapChunkWords
+		end := start + snapChunkWords
+		if end > len(s.uncompressed) {
+			end = len(s.uncompressed)
+		}
+		chunk := make([]uint32, end-start)
+		copy(chunk, s.uncompressed[start:end])
+		return chunk
+	}
+
+	for i := len(prev.chunks); i < nChunks; i++ {
+		chunks[i] = rebuild(i)
+	}
+	if touchedChunk < len(prev.chunks) {
+		chunks[touchedChunk] = rebuild(touchedChunk)
+	}
+
+	s.snap.Store(&bitArrayState{typ: BitArrayType, chunks: chunks})
+}
+
+// publishAfterSet republishes following Set's call to s.set(index, val). It takes
+// the cheap per-word path when the snapshot was already BitArrayType and stays
+// BitArrayType, and falls back to a full publish for anything else: the one-time
+// IndexesType->BitArrayType promotion, or while still in IndexesType (where
+// each such Set copies the whole offsets map).
+func (s *BitArray) publishAfterSet(index uint32) {
+	prev := s.snap.Load()
+	if s.state == BitArrayType && prev != nil && prev.typ == BitArrayType {
+		s.publishWord(prev, index)
+		return
+	}
+	s.publish()
+}
+
+// getFromState reads a single bit out of a snapshot without any locking or
+// mutation, decoding WAH words a run at a time instead of expanding them.
+func getFromState(st *bitArrayState, index uint32) bool {
+	switch st.typ {
+	case IndexesType:
+		return st.offsets[index]
+	case WAHType:
+		return getFromWAH(st.compressed, index)
+	default:
+		pointer := int(index >> 5)
+		chunkIdx := pointer / snapChunkWords
+		if chunkIdx >= len(st.chunks) {
+			return false
+		}
+		within := pointer % snapChunkWords
+		chunk := st.chunks[chunkIdx]
+		if within >= len(chunk) {
+			return false
+		}
+		mask := 1 << (31 - (index % 32))
+		return chunk[within]&uint32(mask) != 0
+	}
+}
+
+// getFromWAH reports whether index is set, scanning compressed WAH words without
+// decompressing them.
+func getFromWAH(words []uint32, index uint32) bool {
+	var pos uint32
+	for _, w := range words {
+		if w&0x80000000 == 0 {
+			const width = 31
+			if index < pos+width {
+				return w&(1<<(width-1-(index-pos))) != 0
+			}
+			pos += width
+		} else {
+			count := w & 0x3fffffff
+			if index < pos+count {
+				return w&0x40000000 != 0
+			}
+			pos += count
+		}
+	}
+	return false
+}
+
+// readLock acquires mux for a read-only operation and returns the function to
+// release it. If s.state is WAHType, the operation may decompress s as a caching
+// side effect (see checkBitArray), which isn't safe under a shared lock, so
+// readLock takes the write lock instead in that case.
+func (s *BitArray) readLock() (unlock func()) {
+	s.mux.RLock()
+	if s.state == WAHType {
+		s.mux.RUnlock()
+		s.mux.Lock()
+		return s.mux.Unlock
+	}
+	return s.mux.RUnlock
 }
 
 func get(s *BitArray, index uint32) bool {
@@ -309,9 +494,13 @@ func (s *BitArray) unpackOffsets() []uint32 {
 	if len(s.offsets) == 0 {
 		return []uint32{}
 	}
-	var max uint32
 	k := s.getOffsets()
-	max = k[len(k)-1]
+	var max uint32
+	for _, index := range k {
+		if index > max {
+			max = index
+		}
+	}
 	ints := make([]uint32, (max>>5)+1)
 
 	for _, index := range k {
@@ -322,9 +511,15 @@ func (s *BitArray) unpackOffsets() []uint32 {
 	return ints
 }
 
+// prelogic reads both operands' bits as equal-length word slices. The caller is
+// expected to already hold s's readLock; op is a different receiver so it needs
+// its own.
 func (s *BitArray) prelogic(op *BitArray) (left, right []uint32) {
 	s.checkBitArray()
 	left = s.getBitArray()
+
+	opUnlock := op.readLock()
+	defer opUnlock()
 	right = op.getBitArray()
 	ic, uc := len(left), len(right)
 	if ic > uc {
@@ -341,8 +536,8 @@ func (s *BitArray) prelogic(op *BitArray) (left, right []uint32) {
 
 // And performs the bitwise AND operation.
 func (s *BitArray) And(op *BitArray) *BitArray {
-	s.mux.Lock()
-	defer s.mux.Unlock()
+	unlock := s.readLock()
+	defer unlock()
 	left, right := s.prelogic(op)
 	for i := 0; i < len(left); i++ {
 		left[i] &= right[i]
@@ -352,8 +547,8 @@ func (s *BitArray) And(op *BitArray) *BitArray {
 
 // Or performs the bitwise OR operation.
 func (s *BitArray) Or(op *BitArray) *BitArray {
-	s.mux.Lock()
-	defer s.mux.Unlock()
+	unlock := s.readLock()
+	defer unlock()
 	left, right := s.prelogic(op)
 	for i := 0; i < len(left); i++ {
 		left[i] |= right[i]
@@ -363,8 +558,8 @@ func (s *BitArray) Or(op *BitArray) *BitArray {
 
 // Not inverts all the bit values.
 func (s *BitArray) Not(size int) *BitArray {
-	s.mux.Lock()
-	defer s.mux.Unlock()
+	unlock := s.readLock()
+	defer unlock()
 	s.checkBitArray()
 	var (
 		left = s.getBitArray()
@@ -389,8 +584,8 @@ func (s *BitArray) Not(size int) *BitArray {
 
 // Xor performs the bitwise exclusive OR operation.
 func (s *BitArray) Xor(op *BitArray) *BitArray {
-	s.mux.Lock()
-	defer s.mux.Unlock()
+	unlock := s.readLock()
+	defer unlock()
 	left, right := s.prelogic(op)
 	for i := 0; i < len(left); i++ {
 		left[i] ^= right[i]
@@ -400,19 +595,26 @@ func (s *BitArray) Xor(op *BitArray) *BitArray {
 
 // FreeMemory compresses all the bit values and free used memory.
 func (s *BitArray) FreeMemory() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	if s.state == BitArrayType {
 		if len(s.uncompressed) > 0 {
 			s.compress(s.uncompressed)
 			s.uncompressed = s.uncompressed[:0]
 			s.state = WAHType
+			s.publish()
 		}
 	}
 }
 
 // GetBitIndexes returns all indexe list that index bit value is true.
 func (s *BitArray) GetBitIndexes() []uint32 {
+	unlock := s.readLock()
+	defer unlock()
 	if s.state == IndexesType {
-		return s.getBitArray()
+		keys := s.getOffsets()
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		return keys
 	}
 	s.checkBitArray()
 	var list []uint32
@@ -429,8 +631,12 @@ func (s *BitArray) GetBitIndexes() []uint32 {
 	return list
 }
 
-// GetCompressed returns index list that has compressed.
+// GetCompressed returns index list that has compressed. It always rebuilds the
+// compressed cache as a side effect, so unlike GetBitIndexes it needs the write
+// lock rather than a shared one.
 func (s *BitArray) GetCompressed() ([]uint32, Type) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	typ := WAHType
 	s.changeTypeIfNeeded()
 	if s.state == IndexesType {
@@ -446,17 +652,33 @@ func (s *BitArray) GetCompressed() ([]uint32, Type) {
 }
 
 // Set sets the bit at a specific position in the BitArray to the specified value.
+// Set panics if the BitArray has been Frozen.
 func (s *BitArray) Set(index uint32, val bool) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
+	if s.frozen {
+		panic("bitarray: Set called on a frozen BitArray")
+	}
 	s.set(index, val)
+	s.publishAfterSet(index)
 }
 
-// Get gets the value of the bit at a specific position in the BitArray.
+// Get gets the value of the bit at a specific position in the BitArray. Get never
+// takes mux: it reads the snapshot last published by Set, Create or
+// UnmarshalBinary, so it scales with concurrent readers and never blocks on a
+// concurrent writer.
 func (s *BitArray) Get(index uint32) bool {
+	return getFromState(s.snap.Load(), index)
+}
+
+// Freeze makes the BitArray immutable: Get, GetBitIndexes, GetCompressed and the
+// binary operations continue to work afterwards, but any later call to Set panics.
+// Freezing doesn't change locking: Get never takes mux, frozen or not, and the
+// other read operations still take it.
+func (s *BitArray) Freeze() {
 	s.mux.Lock()
 	defer s.mux.Unlock()
-	return s.get(index)
+	s.frozen = true
 }
 
 // Parse creates new BitArray with given data.
@@ -478,13 +700,16 @@ func Create(typ Type, ints []uint32) *BitArray {
 			s.offsets[i] = true
 		}
 	}
+	s.publish()
 	return s
 }
 
 // New returns a new BitArray.
 func New() *BitArray {
-	return &BitArray{
+	s := &BitArray{
 		state:   IndexesType,
 		offsets: make(map[uint32]bool),
 	}
+	s.publish()
+	return s
 }
@@ -0,0 +1,61 @@
+package bitarray
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetGet64(t *testing.T) {
+	data := map[uint64]bool{
+		0:           true,
+		1:           true,
+		3:           false,
+		100:         false,
+		100000:      true,
+		4294967295:  true,
+		4294967296:  true, // beyond the uint32 range BitArray can address
+		5000000000:  true,
+		5000000001:  false,
+	}
+	bitset := New64()
+	for index, val := range data {
+		bitset.Set(index, val)
+	}
+	for index, val := range data {
+		b := bitset.Get(index)
+		if b != val {
+			t.Fatalf("expected index %v value is %v but got %v", index, val, b)
+		}
+	}
+}
+
+func TestAnd64(t *testing.T) {
+	b1 := New64()
+	count := 10
+	for i := 0; i < count; i++ {
+		b1.Set(uint64(i), true)
+	}
+	b2 := New64()
+	for i := 5; i < count; i++ {
+		b2.Set(uint64(i), true)
+	}
+	x := b1.And(b2)
+	bits := x.GetBitIndexes()
+	if !reflect.DeepEqual(bits, []uint64{5, 6, 7, 8, 9}) {
+		t.Fatalf("expected index list is [5,6,7,8,9],but got %v", bits)
+	}
+}
+
+func TestXor64(t *testing.T) {
+	b1 := New64()
+	count := 70
+	for i := 0; i < count; i++ {
+		b1.Set(uint64(i), true)
+	}
+	bits, typ := b1.GetCompressed()
+	b2 := Create64(typ, bits)
+	x := b1.Xor(b2)
+	if l := len(x.GetBitIndexes()); l != 0 {
+		t.Fatalf("expected no bits set after xoring a bit array with itself, got %d", l)
+	}
+}
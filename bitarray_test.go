@@ -91,29 +91,11 @@ func TestXor(t *testing.T) {
 	bits, typ := b1.GetCompressed()
 	b2 := Create(typ, bits)
 	x := b1.Xor(b2)
-	if l := countOnes(x); l != 0 {
-		t.Fatalf("countOnes(x)!=0")
+	if l := x.PopCount(); l != 0 {
+		t.Fatalf("PopCount(x)!=0")
 	}
 }
 
-func countOnes(s *BitArray) int {
-	BitCount := func(n uint32) uint32 {
-		n -= ((n >> 1) & 0x55555555)
-		n = (((n >> 2) & 0x33333333) + (n & 0x33333333))
-		n = (((n >> 4) + n) & 0x0f0f0f0f)
-		return ((n * 0x01010101) >> 24)
-	}
-	if s.state == IndexesType {
-		return len(s.offsets)
-	}
-	c := 0
-	s.checkBitArray()
-	for _, i := range s.uncompressed {
-		c += int(BitCount(i))
-	}
-	return int(c)
-}
-
 func countZeros(s *BitArray) int {
 	if s.state == IndexesType {
 		ones := len(s.offsets)
@@ -124,6 +106,6 @@ func countZeros(s *BitArray) int {
 
 	s.checkBitArray()
 	count := len(s.uncompressed) << 5
-	cc := countOnes(s)
-	return count - cc
+	cc := s.PopCount()
+	return count - int(cc)
 }
@@ -0,0 +1,48 @@
+package bitarray
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFreezePanicsOnSet(t *testing.T) {
+	b := New()
+	b.Set(5, true)
+	b.Freeze()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("Set on a frozen BitArray did not panic")
+		}
+	}()
+	b.Set(6, true)
+}
+
+func TestGetConcurrentWithSet(t *testing.T) {
+	b := New()
+	for i := uint32(0); i < 50; i++ {
+		b.Set(i, true)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := uint32(0); i < 50; i++ {
+				if !b.Get(i) {
+					t.Errorf("Get(%d) = false, want true", i)
+				}
+			}
+		}()
+	}
+	b.Set(100, true)
+	wg.Wait()
+
+	if !b.Get(100) {
+		t.Fatalf("Get(100) = false, want true")
+	}
+	if b.Get(101) {
+		t.Fatalf("Get(101) = true, want false")
+	}
+}
@@ -0,0 +1,235 @@
+// +build ignore
+
+// gen.go derives bitarray64.go from bitarray.go, the same way cmd/index/suffixarray
+// generates its 64-bit variant from the 32-bit source: the two implementations are
+// kept in sync by mechanical substitution rather than hand editing.
+//
+// Several things need more than a blind s/32/64/:
+//
+//   - write31Bits/take31Bits pack a 31-bit literal across a pair of 32-bit words
+//     using a uint64 as scratch space. Go has no uint128, so their 64-bit
+//     counterparts are swapped in as hand-written, word-boundary-safe replacements
+//     rather than being substituted token by token.
+//   - the unexported get/set/resizeAsNeeded/writeOnes/flushOnes/flushZeros helpers
+//     are free functions, not methods, so their 64-bit forms need the "64" suffix
+//     to avoid redeclaring the originals in the same package; the statements that
+//     name them are rewritten individually so that the *methods* of the same name
+//     (BitArray64.get, BitArray64.set, ...) are left alone.
+//   - writeOnes64's trailing-bits mask is built from a bare 0xffffffffffffffff
+//     constant, which (unlike its 0xffffffff counterpart) overflows an untyped int;
+//     that one statement gets an explicit uint64() conversion.
+//   - bitArrayState, chunkWords, getFromState, getFromWAH and snapChunkWords are
+//     free names (not methods), so like get/set/resizeAsNeeded above they need a
+//     "64" suffix to avoid redeclaring the originals; see identSubs.
+//   - bitarray.go's package doc comment describes BitArray64 as "generated from
+//     this file", which would be self-referential nonsense if copied verbatim into
+//     bitarray64.go itself, so it's dropped rather than substituted; the package's
+//     one doc comment lives in bitarray.go.
+//   - binary.go, rank.go and iterator.go are never read by this generator, so
+//     MarshalBinary/ReadFrom, PopCount/Rank/Select and Iterator/*Stream have no
+//     BitArray64 counterpart; the ranks field exists only for struct-layout
+//     parity and says so instead of describing machinery that isn't here.
+//   - Type and its BitArrayType/WAHType/IndexesType constants are shared by both
+//     implementations (GetCompressed returns a Type regardless of index width),
+//     so that declaration is dropped rather than copied, to avoid redeclaring it.
+//   - uncompress/compress call write31Bits/take31Bits by name; since funcRE only
+//     swaps in the write63Bits/take63Bits definitions above, those two call sites
+//     get the same rename via statementSubs.
+package main
+
+import (
+	"go/format"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// tokenSubs is applied first, across the whole file.
+var tokenSubs = []struct{ old, new string }{
+	{"0xc0000000", "0xc000000000000000"},
+	{"0x80000000", "0x8000000000000000"},
+	{"0x7fffffff", "0x7fffffffffffffff"},
+	{"0x40000000", "0x4000000000000000"},
+	{"0x3fffffff", "0x3fffffffffffffff"},
+	{"0xffffffff", "0xffffffffffffffff"},
+	{"uint32", "uint64"},
+	{"int32", "int64"},
+}
+
+var (
+	bitArrayIdent = regexp.MustCompile(`\bBitArray\b`)
+	shiftByFive   = regexp.MustCompile(`([<>]{2})\s*5\b`)
+	wordWidth     = regexp.MustCompile(`\b(31|32|33)\b`)
+	wordWidthNew  = map[string]string{"31": "63", "32": "64", "33": "65"}
+
+	// packageDoc matches bitarray.go's leading package doc comment, which isn't
+	// carried into bitarray64.go: the package's one doc comment lives in
+	// bitarray.go, and its text is specific to BitArray/uint32 anyway.
+	packageDoc = regexp.MustCompile(`(?s)^/\*.*?\*/\n`)
+
+	// typeBlock matches the Type declaration, which both implementations share
+	// unchanged (see the note atop this file), so it's dropped from the copy
+	// rather than redeclared.
+	typeBlock = regexp.MustCompile(`(?s)\ntype Type int\n\nconst \(\n.*?\n\)\n`)
+)
+
+// identSubs renames bitarray.go's free (non-method) package-level names that
+// would otherwise collide with the originals when both files are compiled into
+// the same package, the same way statementSubs does for get/set/resizeAsNeeded.
+var identSubs = []struct {
+	re  *regexp.Regexp
+	new string
+}{
+	{regexp.MustCompile(`\bbitArrayState\b`), "bitArrayState64"},
+	{regexp.MustCompile(`\bchunkWords\b`), "chunkWords64"},
+	{regexp.MustCompile(`\bgetFromState\b`), "getFromState64"},
+	{regexp.MustCompile(`\bgetFromWAH\b`), "getFromWAH64"},
+	{regexp.MustCompile(`\bsnapChunkWords\b`), "snapChunkWords64"},
+}
+
+// statementSubs renames the free-function definitions and their call sites. Each
+// pattern is the exact post-tokenSubs statement text, so it can't accidentally
+// match a dotted method call (s.get(...), op.set(...)) that must keep its name.
+var statementSubs = []struct{ old, new string }{
+	{"func get(s *BitArray64, index uint64) bool {", "func get64(s *BitArray64, index uint64) bool {"},
+	{"func set(s *BitArray64, index uint64, val bool) {", "func set64(s *BitArray64, index uint64, val bool) {"},
+	{"return get(s, index)", "return get64(s, index)"},
+	{"set(s, index, val)", "set64(s, index, val)"},
+	{"resizeAsNeeded(", "resizeAsNeeded64("},
+	{"writeOnes(", "writeOnes64("},
+	{"flushOnes(", "flushOnes64("},
+	{"flushZeros(", "flushZeros64("},
+	{"func Create(typ Type, ints []uint64) *BitArray64 {", "func Create64(typ Type, ints []uint64) *BitArray64 {"},
+	{"return Create(BitArrayType, left)", "return Create64(BitArrayType, left)"},
+	{"func New() *BitArray64 {", "func New64() *BitArray64 {"},
+	// uncompress/compress call write31Bits/take31Bits by name rather than through
+	// the funcRE-matched definitions above, so their call sites need the same
+	// hand-written-replacement rename the definitions get.
+	{"write31Bits(list, index, ci)", "write63Bits(list, index, ci)"},
+	{"take31Bits(data, uint64(i))", "take63Bits(data, uint64(i))"},
+	// The 32-bit source's "i := 0xffffffff << ..." relies on 0xffffffff fitting an
+	// untyped int; its widened 0xffffffffffffffff doesn't, so the constant needs an
+	// explicit uint64() conversion that the 32-bit statement doesn't need.
+	{"i := 0xffffffffffffffff << uint64(64-ccount)", "i := uint64(0xffffffffffffffff) << uint64(64-ccount)"},
+	// ranks is never populated or read here: rank.go (ensureRanks, Rank, Select)
+	// is never fed into this generator, so BitArray64 has no rank machinery to
+	// describe. The field stays only so the struct mirrors BitArray's layout.
+	{
+		`	// ranks holds, for BitArrayType, the cumulative popcount at every
+	// rankSampleStride-th word. It is built lazily by ensureRanks and treated as
+	// stale whenever isDirty is set, so Set doesn't pay for upkeep it may never need.
+	ranks []uint64`,
+		`	// ranks is unused: rank.go's PopCount/Rank/Select machinery has no
+	// BitArray64 counterpart (see the note atop gen.go). The field is kept only
+	// so this struct mirrors BitArray's layout.
+	ranks []uint64`,
+	},
+	// Comments that name bitarray.go's UnmarshalBinary/unmarshalValues (binary.go,
+	// which has no BitArray64 counterpart) or the 32-bit constructor names are
+	// reworded to name what BitArray64 actually has.
+	{"published by Set, Create,\n\t// unmarshalValues or FreeMemory,", "published by Set, Create64,\n\t// New64 or FreeMemory,"},
+	{"from scratch; Create,\n// New, UnmarshalBinary and FreeMemory use it,", "from scratch; Create64,\n// New64 and FreeMemory use it,"},
+	{"published by Set, Create or\n// UnmarshalBinary,", "published by Set, Create64 or\n// FreeMemory,"},
+	{"// Parse creates new BitArray64 with given data.", "// Create64 creates new BitArray64 with given data."},
+	{"// New returns a new BitArray64.", "// New64 returns a new BitArray64."},
+}
+
+const write63Bits = `
+func write63Bits(list []uint64, index int64, val uint64) []uint64 {
+	list = resizeAsNeeded64(list, index+64)
+	var (
+		off     = index % 64
+		pointer = index >> 6
+	)
+	if int(pointer) >= len(list)-1 {
+		list = append(list, 0)
+	}
+
+	// Go has no uint128, so the two adjoining 64-bit words are combined as an
+	// explicit high/low pair instead of the single uint64 scratch value the
+	// 32-bit implementation uses for its 32+32 combiner.
+	hi, lo := list[pointer], list[pointer+1]
+	shift := uint(65 - off)
+	if shift >= 64 {
+		hi |= val << (shift - 64)
+	} else {
+		hi |= val >> (64 - shift)
+		lo |= val << shift
+	}
+
+	list[pointer] = hi
+	list[pointer+1] = lo
+	return list
+}
+`
+
+const take63Bits = `
+func take63Bits(data []uint64, index uint64) uint64 {
+	var hi, lo uint64
+	off := index % 64
+	pointer := index >> 6
+	hi = data[pointer]
+	pointer++
+	if int(pointer) < len(data) {
+		lo = data[pointer]
+	}
+
+	shift := uint(65 - off)
+	var ret uint64
+	if shift >= 64 {
+		ret = hi >> (shift - 64)
+	} else {
+		ret = (hi << (64 - shift)) | (lo >> shift)
+	}
+	return ret & 0x7fffffffffffffff
+}
+`
+
+var funcRE = regexp.MustCompile(`(?s)\nfunc (write31Bits|take31Bits)\(.*?\n}\n`)
+
+func main() {
+	src, err := ioutil.ReadFile("bitarray.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+	out := string(src)
+	out = packageDoc.ReplaceAllString(out, "")
+	out = typeBlock.ReplaceAllString(out, "")
+
+	out = funcRE.ReplaceAllStringFunc(out, func(m string) string {
+		if strings.Contains(m, "func write31Bits") {
+			return "\n\x00WRITE63\x00\n"
+		}
+		return "\n\x00TAKE63\x00\n"
+	})
+
+	for _, r := range tokenSubs {
+		out = strings.ReplaceAll(out, r.old, r.new)
+	}
+	out = bitArrayIdent.ReplaceAllString(out, "BitArray64")
+	for _, r := range identSubs {
+		out = r.re.ReplaceAllString(out, r.new)
+	}
+	out = shiftByFive.ReplaceAllString(out, "$1 6")
+	out = wordWidth.ReplaceAllStringFunc(out, func(m string) string { return wordWidthNew[m] })
+
+	for _, r := range statementSubs {
+		out = strings.ReplaceAll(out, r.old, r.new)
+	}
+
+	out = strings.Replace(out, "\x00WRITE63\x00", strings.TrimSpace(write63Bits), 1)
+	out = strings.Replace(out, "\x00TAKE63\x00", strings.TrimSpace(take63Bits), 1)
+
+	header := "// Code generated from bitarray.go by \"go run gen.go\"; DO NOT EDIT.\n\n"
+	// shiftByFive's literal "$1 6" substitution doesn't track gofmt's
+	// precedence-driven spacing around shifts, so the result is formatted
+	// before writing rather than copied out byte for byte.
+	formatted, err := format.Source([]byte(header + out))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("bitarray64.go", formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
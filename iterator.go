@@ -0,0 +1,286 @@
+package bitarray
+
+import "sort"
+
+// Iterator yields the set-bit indices of a BitArray in ascending order, reading
+// directly from whatever internal representation the BitArray is currently in
+// instead of materializing it. It is not safe for concurrent use.
+type Iterator struct {
+	it bitIter
+}
+
+// bitIter is implemented once per internal state so Iterator itself stays a thin
+// wrapper; And/Or/XorStream only ever see the Iterator, not the concrete state.
+type bitIter interface {
+	Next() (uint32, bool)
+	AdvanceTo(min uint32)
+}
+
+// Next returns the next set-bit index in ascending order, or false once exhausted.
+func (it *Iterator) Next() (uint32, bool) {
+	return it.it.Next()
+}
+
+// AdvanceTo skips the iterator forward so the next call to Next returns an index
+// >= min. It is a no-op if the iterator is already positioned at or past min.
+func (it *Iterator) AdvanceTo(min uint32) {
+	it.it.AdvanceTo(min)
+}
+
+// Iterator returns an Iterator over s's set-bit indices.
+func (s *BitArray) Iterator() *Iterator {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	switch s.state {
+	case IndexesType:
+		keys := s.getOffsets()
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		return &Iterator{it: &indexesIterator{keys: keys}}
+	case WAHType:
+		return &Iterator{it: &wahIterator{words: s.compressed}}
+	default:
+		s.checkBitArray()
+		words := append([]uint32(nil), s.uncompressed...)
+		return &Iterator{it: &bitArrayIterator{words: words}}
+	}
+}
+
+type indexesIterator struct {
+	keys []uint32
+	pos  int
+}
+
+func (it *indexesIterator) Next() (uint32, bool) {
+	if it.pos >= len(it.keys) {
+		return 0, false
+	}
+	v := it.keys[it.pos]
+	it.pos++
+	return v, true
+}
+
+func (it *indexesIterator) AdvanceTo(min uint32) {
+	if it.pos < len(it.keys) && it.keys[it.pos] >= min {
+		return
+	}
+	it.pos += sort.Search(len(it.keys)-it.pos, func(i int) bool {
+		return it.keys[it.pos+i] >= min
+	})
+}
+
+// bitArrayIterator bit-scans the uncompressed words, skipping whole zero words.
+type bitArrayIterator struct {
+	words   []uint32
+	wordIdx int
+	bitIdx  uint32
+}
+
+func (it *bitArrayIterator) Next() (uint32, bool) {
+	for it.wordIdx < len(it.words) {
+		w := it.words[it.wordIdx]
+		if w != 0 {
+			for it.bitIdx < 32 {
+				b := it.bitIdx
+				it.bitIdx++
+				if w&(1<<(31-b)) != 0 {
+					return uint32(it.wordIdx)*32 + b, true
+				}
+			}
+		}
+		it.wordIdx++
+		it.bitIdx = 0
+	}
+	return 0, false
+}
+
+func (it *bitArrayIterator) AdvanceTo(min uint32) {
+	cur := uint32(it.wordIdx)*32 + it.bitIdx
+	if min <= cur {
+		return
+	}
+	it.wordIdx = int(min >> 5)
+	it.bitIdx = min % 32
+}
+
+// wahIterator decodes WAH fill/literal words on the fly. off counts how much of
+// the current word's run (a 31-bit literal or a fill of `width` bits) has already
+// been consumed, so AdvanceTo can skip an entire fill run in O(1).
+type wahIterator struct {
+	words []uint32
+
+	idx     int
+	base    uint32
+	width   uint32
+	off     uint32
+	kind    byte // 0 = zero fill, 1 = ones fill, 2 = literal
+	literal uint32
+}
+
+func (it *wahIterator) ensure() bool {
+	if it.width > 0 && it.off < it.width {
+		return true
+	}
+	if it.width > 0 {
+		it.base += it.width
+		it.idx++
+		it.width = 0
+	}
+	if it.idx >= len(it.words) {
+		return false
+	}
+
+	w := it.words[it.idx]
+	if w&0x80000000 == 0 {
+		it.kind = 2
+		it.width = 31
+		it.literal = w
+	} else {
+		it.width = w & 0x3fffffff
+		if w&0x40000000 > 0 {
+			it.kind = 1
+		} else {
+			it.kind = 0
+		}
+	}
+	it.off = 0
+	return true
+}
+
+func (it *wahIterator) Next() (uint32, bool) {
+	for it.ensure() {
+		switch it.kind {
+		case 2:
+			for it.off < it.width {
+				b := it.off
+				it.off++
+				if it.literal&(1<<(30-b)) != 0 {
+					return it.base + b, true
+				}
+			}
+		case 1:
+			pos := it.base + it.off
+			it.off++
+			return pos, true
+		default: // zero fill, nothing to yield
+			it.off = it.width
+		}
+	}
+	return 0, false
+}
+
+func (it *wahIterator) AdvanceTo(min uint32) {
+	for it.ensure() {
+		if it.base+it.width <= min {
+			it.off = it.width
+			continue
+		}
+		if min > it.base+it.off {
+			it.off = min - it.base
+		}
+		return
+	}
+}
+
+// AndStream consumes a and b in a single forward pass and returns their bitwise
+// AND as a WAH-compressed BitArray, without decompressing either operand. It
+// gallops the behind iterator forward with AdvanceTo past runs that can't match.
+func AndStream(a, b *Iterator) *BitArray {
+	var positions []uint32
+	pa, oka := a.Next()
+	pb, okb := b.Next()
+	for oka && okb {
+		switch {
+		case pa == pb:
+			positions = append(positions, pa)
+			pa, oka = a.Next()
+			pb, okb = b.Next()
+		case pa < pb:
+			a.AdvanceTo(pb)
+			pa, oka = a.Next()
+		default:
+			b.AdvanceTo(pa)
+			pb, okb = b.Next()
+		}
+	}
+	return buildStreamed(positions)
+}
+
+// OrStream consumes a and b in a single forward pass and returns their bitwise OR
+// as a WAH-compressed BitArray, without decompressing either operand.
+func OrStream(a, b *Iterator) *BitArray {
+	var positions []uint32
+	pa, oka := a.Next()
+	pb, okb := b.Next()
+	for oka || okb {
+		switch {
+		case oka && okb:
+			switch {
+			case pa == pb:
+				positions = append(positions, pa)
+				pa, oka = a.Next()
+				pb, okb = b.Next()
+			case pa < pb:
+				positions = append(positions, pa)
+				pa, oka = a.Next()
+			default:
+				positions = append(positions, pb)
+				pb, okb = b.Next()
+			}
+		case oka:
+			positions = append(positions, pa)
+			pa, oka = a.Next()
+		default:
+			positions = append(positions, pb)
+			pb, okb = b.Next()
+		}
+	}
+	return buildStreamed(positions)
+}
+
+// XorStream consumes a and b in a single forward pass and returns their bitwise
+// exclusive OR as a WAH-compressed BitArray, without decompressing either operand.
+func XorStream(a, b *Iterator) *BitArray {
+	var positions []uint32
+	pa, oka := a.Next()
+	pb, okb := b.Next()
+	for oka || okb {
+		switch {
+		case oka && okb:
+			switch {
+			case pa == pb:
+				pa, oka = a.Next()
+				pb, okb = b.Next()
+			case pa < pb:
+				positions = append(positions, pa)
+				pa, oka = a.Next()
+			default:
+				positions = append(positions, pb)
+				pb, okb = b.Next()
+			}
+		case oka:
+			positions = append(positions, pa)
+			pa, oka = a.Next()
+		default:
+			positions = append(positions, pb)
+			pb, okb = b.Next()
+		}
+	}
+	return buildStreamed(positions)
+}
+
+// buildStreamed turns a stream of ascending set-bit positions into a
+// WAH-compressed BitArray. It builds through the unexported resize/set helpers
+// rather than the public Set, so nothing is republished per position; the
+// snapshot Create(BitArrayType, nil) stored at the top stays empty until the
+// publish at the end.
+func buildStreamed(positions []uint32) *BitArray {
+	result := Create(BitArrayType, nil)
+	for _, p := range positions {
+		result.resize(p)
+		set(result, p, true)
+	}
+	result.FreeMemory()
+	result.publish()
+	return result
+}
@@ -0,0 +1,98 @@
+package bitarray
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIteratorStates(t *testing.T) {
+	want := []uint32{1, 5, 40, 41, 100}
+
+	indexesArr := New()
+	for _, i := range want {
+		indexesArr.Set(i, true)
+	}
+
+	// padding is disjoint from want and only exists to push the array past
+	// BitmapOffsetSwitchOverCount into BitArrayType.
+	padding := []uint32{200, 201, 202, 203, 204, 205, 206, 207, 208, 209, 210, 211}
+
+	bitArrayArr := New()
+	for _, i := range padding {
+		bitArrayArr.Set(i, true)
+	}
+	for _, i := range want {
+		bitArrayArr.Set(i, true)
+	}
+	for _, i := range padding {
+		bitArrayArr.Set(i, false)
+	}
+
+	wahArr := New()
+	for _, i := range want {
+		wahArr.Set(i, true)
+	}
+	for _, i := range padding {
+		wahArr.Set(i, true)
+	}
+	for _, i := range padding {
+		wahArr.Set(i, false)
+	}
+	wahArr.FreeMemory()
+
+	for name, b := range map[string]*BitArray{"indexes": indexesArr, "bitarray": bitArrayArr, "wah": wahArr} {
+		it := b.Iterator()
+		var got []uint32
+		for {
+			v, ok := it.Next()
+			if !ok {
+				break
+			}
+			got = append(got, v)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: Iterator() yielded %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestIteratorAdvanceTo(t *testing.T) {
+	b := New()
+	for _, i := range []uint32{1, 5, 40, 41, 100} {
+		b.Set(i, true)
+	}
+	it := b.Iterator()
+	it.AdvanceTo(40)
+	v, ok := it.Next()
+	if !ok || v != 40 {
+		t.Fatalf("Next() after AdvanceTo(40) = (%d, %v), want (40, true)", v, ok)
+	}
+}
+
+func TestStreamOps(t *testing.T) {
+	b1 := New()
+	for i := 0; i < 10; i++ {
+		b1.Set(uint32(i), true)
+	}
+	b2 := New()
+	for i := 5; i < 15; i++ {
+		b2.Set(uint32(i), true)
+	}
+
+	and := AndStream(b1.Iterator(), b2.Iterator())
+	if got := and.GetBitIndexes(); !reflect.DeepEqual(got, []uint32{5, 6, 7, 8, 9}) {
+		t.Fatalf("AndStream = %v, want [5 6 7 8 9]", got)
+	}
+
+	or := OrStream(b1.Iterator(), b2.Iterator())
+	want := []uint32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+	if got := or.GetBitIndexes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("OrStream = %v, want %v", got, want)
+	}
+
+	xor := XorStream(b1.Iterator(), b2.Iterator())
+	want = []uint32{0, 1, 2, 3, 4, 10, 11, 12, 13, 14}
+	if got := xor.GetBitIndexes(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("XorStream = %v, want %v", got, want)
+	}
+}
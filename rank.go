@@ -0,0 +1,235 @@
+package bitarray
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// rankSampleStride is the number of uncompressed words between successive
+// entries of s.ranks.
+const rankSampleStride = 8
+
+// PopCount returns the total number of set bits.
+func (s *BitArray) PopCount() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.popCount()
+}
+
+// Rank returns the number of set bits at a position less than i.
+func (s *BitArray) Rank(i uint32) uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.rank(i)
+}
+
+// Select returns the position of the k-th set bit (0-indexed), and false if the
+// BitArray has k or fewer bits set.
+func (s *BitArray) Select(k uint64) (uint32, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.selectBit(k)
+}
+
+func (s *BitArray) popCount() uint64 {
+	switch s.state {
+	case IndexesType:
+		return uint64(len(s.offsets))
+	case WAHType:
+		var total uint64
+		for _, w := range s.compressed {
+			if w&0x80000000 == 0 {
+				total += uint64(bits.OnesCount32(w))
+			} else if w&0x40000000 > 0 {
+				total += uint64(w & 0x3fffffff)
+			}
+		}
+		return total
+	default:
+		return s.rankBitArray(uint32(len(s.uncompressed)) * 32)
+	}
+}
+
+func (s *BitArray) rank(i uint32) uint64 {
+	switch s.state {
+	case IndexesType:
+		var rank uint64
+		for idx := range s.offsets {
+			if idx < i {
+				rank++
+			}
+		}
+		return rank
+	case WAHType:
+		return rankWAH(s.compressed, i)
+	default:
+		return s.rankBitArray(i)
+	}
+}
+
+func (s *BitArray) selectBit(k uint64) (uint32, bool) {
+	switch s.state {
+	case IndexesType:
+		keys := s.getOffsets()
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		if k >= uint64(len(keys)) {
+			return 0, false
+		}
+		return keys[k], true
+	case WAHType:
+		return selectWAH(s.compressed, k)
+	default:
+		return s.selectBitArray(k)
+	}
+}
+
+// ensureRanks (re)builds s.ranks if it is missing or stale. It is a no-op outside
+// BitArrayType, since WAHType words are already cheap to rank a word at a time and
+// IndexesType has no word layout to sample.
+func (s *BitArray) ensureRanks() {
+	if s.state != BitArrayType {
+		return
+	}
+	if !s.isDirty && s.ranks != nil {
+		return
+	}
+
+	n := len(s.uncompressed)
+	ranks := make([]uint32, n/rankSampleStride+1)
+	var cum uint32
+	for i := 0; i < n; i++ {
+		if i%rankSampleStride == 0 {
+			ranks[i/rankSampleStride] = cum
+		}
+		cum += uint32(bits.OnesCount32(s.uncompressed[i]))
+	}
+	if n%rankSampleStride == 0 {
+		ranks[n/rankSampleStride] = cum
+	}
+	s.ranks = ranks
+	s.isDirty = false
+}
+
+func (s *BitArray) rankBitArray(i uint32) uint64 {
+	s.ensureRanks()
+
+	wordIdx := int(i >> 5)
+	bitOff := i % 32
+	sampleIdx := wordIdx / rankSampleStride
+	if sampleIdx >= len(s.ranks) {
+		sampleIdx = len(s.ranks) - 1
+	}
+
+	rank := uint64(s.ranks[sampleIdx])
+	for w := sampleIdx * rankSampleStride; w < wordIdx && w < len(s.uncompressed); w++ {
+		rank += uint64(bits.OnesCount32(s.uncompressed[w]))
+	}
+	if wordIdx < len(s.uncompressed) && bitOff > 0 {
+		top := s.uncompressed[wordIdx] >> (32 - bitOff)
+		rank += uint64(bits.OnesCount32(top))
+	}
+	return rank
+}
+
+func (s *BitArray) selectBitArray(k uint64) (uint32, bool) {
+	s.ensureRanks()
+
+	sampleIdx := sort.Search(len(s.ranks), func(i int) bool {
+		return uint64(s.ranks[i]) > k
+	}) - 1
+	if sampleIdx < 0 {
+		sampleIdx = 0
+	}
+
+	rank := uint64(s.ranks[sampleIdx])
+	wordIdx := sampleIdx * rankSampleStride
+	for wordIdx < len(s.uncompressed) {
+		w := s.uncompressed[wordIdx]
+		c := uint64(bits.OnesCount32(w))
+		if rank+c <= k {
+			rank += c
+			wordIdx++
+			continue
+		}
+		for b := uint32(0); b < 32; b++ {
+			if w&(1<<(31-b)) != 0 {
+				if rank == k {
+					return uint32(wordIdx)*32 + b, true
+				}
+				rank++
+			}
+		}
+		wordIdx++
+	}
+	return 0, false
+}
+
+// rankWAH counts the set bits at a position less than i directly from compressed
+// WAH words, decoding fill words in O(1) instead of expanding them.
+func rankWAH(words []uint32, i uint32) uint64 {
+	var rank uint64
+	var pos uint32
+	for _, w := range words {
+		if pos >= i {
+			break
+		}
+		if w&0x80000000 == 0 {
+			width := uint32(31)
+			if pos+width <= i {
+				rank += uint64(bits.OnesCount32(w))
+			} else {
+				remain := i - pos
+				rank += uint64(bits.OnesCount32(w >> (width - remain)))
+			}
+			pos += width
+		} else {
+			count := w & 0x3fffffff
+			if w&0x40000000 > 0 {
+				if pos+count <= i {
+					rank += uint64(count)
+				} else {
+					rank += uint64(i - pos)
+				}
+			}
+			pos += count
+		}
+	}
+	return rank
+}
+
+// selectWAH returns the position of the k-th set bit directly from compressed WAH
+// words, without expanding fill words.
+func selectWAH(words []uint32, k uint64) (uint32, bool) {
+	var rank uint64
+	var pos uint32
+	for _, w := range words {
+		if w&0x80000000 == 0 {
+			c := uint64(bits.OnesCount32(w))
+			if rank+c <= k {
+				rank += c
+				pos += 31
+				continue
+			}
+			for b := uint32(0); b < 31; b++ {
+				if w&(1<<(30-b)) != 0 {
+					if rank == k {
+						return pos + b, true
+					}
+					rank++
+				}
+			}
+			pos += 31
+		} else {
+			count := w & 0x3fffffff
+			if w&0x40000000 > 0 {
+				if rank+uint64(count) <= k {
+					rank += uint64(count)
+				} else {
+					return pos + uint32(k-rank), true
+				}
+			}
+			pos += count
+		}
+	}
+	return 0, false
+}
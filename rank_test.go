@@ -0,0 +1,62 @@
+package bitarray
+
+import "testing"
+
+func TestPopCountRankSelect(t *testing.T) {
+	// More than BitmapOffsetSwitchOverCount entries, so newFilled() ends up in
+	// BitArrayType rather than staying IndexesType.
+	indexes := []uint32{0, 3, 5, 8, 12, 17, 20, 25, 33, 40, 41, 55, 80, 100, 200}
+
+	newFilled := func() *BitArray {
+		b := New()
+		for _, i := range indexes {
+			b.Set(i, true)
+		}
+		return b
+	}
+
+	states := map[string]*BitArray{
+		"indexes": func() *BitArray {
+			b := New()
+			b.Set(0, true)
+			b.Set(3, true)
+			return b
+		}(),
+		"bitarray": newFilled(),
+	}
+	wah := newFilled()
+	wah.FreeMemory()
+	states["wah"] = wah
+
+	for name, b := range states {
+		want := 2
+		if name != "indexes" {
+			want = len(indexes)
+		}
+		if got := int(b.PopCount()); got != want {
+			t.Fatalf("%s: PopCount() = %d, want %d", name, got, want)
+		}
+	}
+
+	b := newFilled()
+	for k, idx := range indexes {
+		got, ok := b.Select(uint64(k))
+		if !ok || got != idx {
+			t.Fatalf("Select(%d) = (%d, %v), want (%d, true)", k, got, ok, idx)
+		}
+		if got := b.Rank(idx + 1); got != uint64(k+1) {
+			t.Fatalf("Rank(%d) = %d, want %d", idx+1, got, k+1)
+		}
+	}
+	if _, ok := b.Select(uint64(len(indexes))); ok {
+		t.Fatalf("Select(%d) should report false, array only has %d bits set", len(indexes), len(indexes))
+	}
+
+	b.FreeMemory()
+	for k, idx := range indexes {
+		got, ok := b.Select(uint64(k))
+		if !ok || got != idx {
+			t.Fatalf("WAH Select(%d) = (%d, %v), want (%d, true)", k, got, ok, idx)
+		}
+	}
+}